@@ -0,0 +1,126 @@
+// Package cgentest is a golden-testing harness for cgen, modeled on
+// golang.org/x/tools/go/analysis/analysistest: point it at a testdata
+// package and it runs the loader and Data.Diff the same way `go
+// generate` would, then compares (or, with -update, rewrites) a golden
+// file.
+package cgentest
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/juliangracin/svckit/cgen/loader"
+)
+
+var update = flag.Bool("update", false, "update cgentest golden files instead of comparing against them")
+
+// Run loads the package at dir, generates Diff/Merge/Copy code for
+// rootType the same way the cgen command would, and compares the result
+// against dir/want.go. With -update, it rewrites want.go instead.
+// rootType may be "", in which case loader.Load picks the type with a
+// cgen:"root" tagged field, same as the cgen command.
+func Run(t *testing.T, dir, rootType string) {
+	t.Helper()
+
+	data, err := loader.Load(dir, rootType)
+	if err != nil {
+		t.Fatalf("loading %s: %v", dir, err)
+	}
+	got, err := data.Diff()
+	if err != nil {
+		t.Fatalf("generating %s: %v", rootType, err)
+	}
+
+	want := filepath.Join(dir, "want.go")
+	if *update {
+		if err := got.Save(want); err != nil {
+			t.Fatalf("updating %s: %v", want, err)
+		}
+		return
+	}
+
+	wantBytes, err := ioutil.ReadFile(want)
+	if err != nil {
+		t.Fatalf("reading golden %s (run with -update to create it): %v", want, err)
+	}
+	if !bytes.Equal(got.Bytes(), wantBytes) {
+		t.Errorf("generated code for %s in %s differs from %s; rerun with -update if the change is intentional:\n--- want\n+++ got\n%s", rootType, dir, want, got.String())
+	}
+}
+
+// Case is a named round-trip assertion run against the checked-in
+// generated types for a package, e.g. checking that
+// old.Diff(new).Merge(old) reconstructs new, or that old.Copy() is deep
+// and independent of old.
+type Case struct {
+	Name   string
+	Assert func(t *testing.T)
+}
+
+// generatedFileName is the file regenerated code is checked into,
+// matching cmd/cgen's -out default.
+const generatedFileName = "cgen_generated.go"
+
+// RunGenerated regenerates Diff/Merge/Copy code for rootType from the
+// package at pkgDir, temporarily overwriting its checked-in
+// cgen_generated.go in place, and builds the package with `go build` —
+// this is what actually catches a template regression, since it fails
+// the same way a stale checked-in generated file would fail `go
+// generate && go build`. Building in place (rather than a copy
+// elsewhere) lets the build resolve pkgDir's imports through the
+// enclosing module exactly as it would for the checked-in file; the
+// original file is restored once the build finishes (if the test binary
+// is killed mid-build, cgen_generated.go is left overwritten in the
+// working tree — recoverable with `git checkout`, since it's a tracked
+// file). It then runs cases, which exercise the real (checked-in)
+// generated types in pkgDir directly. rootType may be "", same as Run.
+func RunGenerated(t *testing.T, pkgDir, rootType string, cases []Case) {
+	t.Helper()
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available, skipping scratch build")
+	}
+
+	data, err := loader.Load(pkgDir, rootType)
+	if err != nil {
+		t.Fatalf("loading %s: %v", pkgDir, err)
+	}
+	code, err := data.Diff()
+	if err != nil {
+		t.Fatalf("generating %s: %v", rootType, err)
+	}
+
+	generated := filepath.Join(pkgDir, generatedFileName)
+	original, err := ioutil.ReadFile(generated)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("reading %s: %v", generated, err)
+	}
+	defer func() {
+		if original == nil {
+			os.Remove(generated)
+			return
+		}
+		if err := ioutil.WriteFile(generated, original, 0644); err != nil {
+			t.Errorf("restoring %s: %v", generated, err)
+		}
+	}()
+
+	if err := code.Save(generated); err != nil {
+		t.Fatalf("writing generated code: %v", err)
+	}
+
+	cmd := exec.Command("go", "build", ".")
+	cmd.Dir = pkgDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("generated code for %s doesn't build:\n%s", rootType, out)
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, c.Assert)
+	}
+}
@@ -0,0 +1,15 @@
+package cgentest_test
+
+import (
+	"testing"
+
+	"github.com/juliangracin/svckit/cgen/cgentest"
+)
+
+// TestGolden exercises cgentest.Run, comparing generated code for golden
+// against its checked-in want.go. Unlike RunGenerated's round-trip cases,
+// this only catches output drift (e.g. a template regression) and never
+// builds or runs the generated code.
+func TestGolden(t *testing.T) {
+	cgentest.Run(t, "./testdata/golden", "Root")
+}
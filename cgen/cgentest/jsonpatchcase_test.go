@@ -0,0 +1,41 @@
+package cgentest_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/juliangracin/svckit/cgen/cgentest"
+	"github.com/juliangracin/svckit/cgen/cgentest/testdata/jsonpatchcase"
+)
+
+// TestJSONPatch exercises JSONPatch/ApplyJSONPatch for untagged fields,
+// in particular a map and a nested struct — the JSON Pointer token for
+// an untagged field must match the Go field name verbatim, since that's
+// what encoding/json emits, not a lowercased version of it.
+func TestJSONPatch(t *testing.T) {
+	cgentest.RunGenerated(t, "./testdata/jsonpatchcase", "Root", []cgentest.Case{
+		{
+			Name: "round trip through json.Marshal",
+			Assert: func(t *testing.T) {
+				o := jsonpatchcase.Root{
+					Info: jsonpatchcase.Info{Age: 1},
+					Tags: map[string]jsonpatchcase.Tag{"x": {V: "1"}},
+				}
+				n := jsonpatchcase.Root{
+					Info: jsonpatchcase.Info{Age: 2},
+					Tags: map[string]jsonpatchcase.Tag{"x": {V: "2"}, "y": {V: "new"}},
+				}
+
+				p := o.JSONPatch(n)
+
+				got, err := o.ApplyJSONPatch(p)
+				if err != nil {
+					t.Fatalf("applying patch %+v: %v", p, err)
+				}
+				if !reflect.DeepEqual(got, n) {
+					t.Fatalf("ApplyJSONPatch(JSONPatch(o, n)) = %+v, want %+v", got, n)
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,43 @@
+package cgentest_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/juliangracin/svckit/cgen/cgentest"
+	"github.com/juliangracin/svckit/cgen/cgentest/testdata/keyedslice"
+)
+
+// TestKeyedSlice exercises the cgen:"key=..." slice path end to end:
+// regenerating and building keyedslice's code (catching a template
+// regression), then round-tripping a reorder and a keyed modify through
+// the checked-in generated types, which a positional merge walk gets
+// wrong (see the {{.Elem}}SliceOp.Old doc comment in cgen/diff.go).
+func TestKeyedSlice(t *testing.T) {
+	cgentest.RunGenerated(t, "./testdata/keyedslice", "Root", []cgentest.Case{
+		{
+			Name: "reorder",
+			Assert: func(t *testing.T) {
+				o := keyedslice.Root{Items: []keyedslice.Item{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}}
+				n := keyedslice.Root{Items: []keyedslice.Item{{ID: 2, Name: "b"}, {ID: 1, Name: "a"}}}
+
+				got := o.Merge(*o.Diff(n))
+				if !reflect.DeepEqual(got, n) {
+					t.Fatalf("merge(diff(o, n)) = %+v, want %+v", got, n)
+				}
+			},
+		},
+		{
+			Name: "keyed modify",
+			Assert: func(t *testing.T) {
+				o := keyedslice.Root{Items: []keyedslice.Item{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}}
+				n := keyedslice.Root{Items: []keyedslice.Item{{ID: 2, Name: "c"}, {ID: 3, Name: "d"}}}
+
+				got := o.Merge(*o.Diff(n))
+				if !reflect.DeepEqual(got, n) {
+					t.Fatalf("merge(diff(o, n)) = %+v, want %+v", got, n)
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,13 @@
+// Package golden is a cgentest fixture exercising cgentest.Run's golden
+// comparison against want.go, as opposed to the round-trip build exercised
+// by RunGenerated (see golden_test.go).
+package golden
+
+type Item struct {
+	Name string
+}
+
+type Root struct {
+	_    struct{} `cgen:"root"`
+	Item Item
+}
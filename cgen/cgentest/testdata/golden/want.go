@@ -0,0 +1,154 @@
+// Code generated by go generate; DO NOT EDIT.
+package golden
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/juliangracin/svckit/cgen"
+	"github.com/juliangracin/svckit/cgen/jsonpatch"
+)
+
+type ItemDiff struct {
+	Name *string
+}
+
+type RootDiff struct {
+	Item *ItemDiff
+}
+
+func (o Item) merge(d *ItemDiff, mo *cgen.MergeOptions) (Item, bool) {
+	if d == nil {
+		return o, false
+	}
+	changed := false
+	// fields
+	if d.Name != nil {
+		if fn, ok := mo.Transformer(reflect.TypeOf(o.Name)); ok {
+			if err := fn(&o.Name, *d.Name); err == nil {
+				changed = true
+			}
+		} else {
+			var zeroName string
+			if (mo.OverwriteEmpty() || *d.Name != zeroName) && *d.Name != o.Name {
+				o.Name = *d.Name
+				changed = true
+			}
+		}
+	}
+	return o, changed
+}
+
+// Merge applies diff (d) to Root (o), configured by opts (see
+// WithOverride, WithoutOverwriteEmpty, WithAppendSlice, WithoutMapDelete,
+// WithTypeTransformer), and returns new value type with merged changes.
+// Doesn't modifies original value (o).
+func (o Root) Merge(d RootDiff, opts ...cgen.MergeOption) Root {
+	n, _ := o.merge(&d, cgen.NewMergeOptions(opts...))
+	return n
+}
+
+func (o Root) merge(d *RootDiff, mo *cgen.MergeOptions) (Root, bool) {
+	if d == nil {
+		return o, false
+	}
+	changed := false
+	// fields
+	// Item field
+	if o2, merged := o.Item.merge(d.Item, mo); merged {
+		o.Item = o2
+		changed = true
+	}
+	return o, changed
+}
+
+func (o Item) diff(n Item) *ItemDiff {
+	i := &ItemDiff{}
+	if n.Name != o.Name {
+		i.Name = &n.Name
+	}
+	if i.empty() {
+		return nil
+	}
+	return i
+}
+
+func (i ItemDiff) empty() bool {
+	return i.Name == nil
+}
+
+// Diff creates diff (i) between new (n) and old (o) Root.
+// So that diff applyed to old will produce new.
+func (o Root) Diff(n Root) *RootDiff {
+	return o.diff(n)
+}
+
+func (o Root) diff(n Root) *RootDiff {
+	i := &RootDiff{}
+	i.Item = o.Item.diff(n.Item)
+	if i.empty() {
+		return nil
+	}
+	return i
+}
+
+func (i RootDiff) empty() bool {
+	return i.Item == nil
+}
+
+func (o Item) Copy() Item {
+	return o
+}
+
+func (o Root) Copy() Root {
+	return o
+}
+
+// ItemJSONPatchOps flattens d (the diff between o and n) into RFC
+// 6902 operations rooted at prefix.
+func ItemJSONPatchOps(prefix string, o, n Item, d *ItemDiff) []jsonpatch.Operation {
+	var ops []jsonpatch.Operation
+	if d.Name != nil {
+		ops = append(ops, jsonpatch.Operation{Op: jsonpatch.OpReplace, Path: prefix + "/Name", Value: *d.Name})
+	}
+	return ops
+}
+
+// JSONPatch returns an RFC 6902 JSON Patch turning o into n, built by
+// walking the same diff as Diff and flattening it into JSON Pointer
+// operations.
+func (o Root) JSONPatch(n Root) []jsonpatch.Operation {
+	d := o.diff(n)
+	if d == nil {
+		return nil
+	}
+	return RootJSONPatchOps("", o, n, d)
+}
+
+// ApplyJSONPatch applies p to o and returns the result.
+func (o Root) ApplyJSONPatch(p []jsonpatch.Operation) (Root, error) {
+	doc, err := json.Marshal(o)
+	if err != nil {
+		return o, fmt.Errorf("marshaling Root: %w", err)
+	}
+	patched, err := jsonpatch.Apply(doc, p)
+	if err != nil {
+		return o, fmt.Errorf("applying json patch to Root: %w", err)
+	}
+	var n Root
+	if err := json.Unmarshal(patched, &n); err != nil {
+		return o, fmt.Errorf("unmarshaling patched Root: %w", err)
+	}
+	return n, nil
+}
+
+// RootJSONPatchOps flattens d (the diff between o and n) into RFC
+// 6902 operations rooted at prefix.
+func RootJSONPatchOps(prefix string, o, n Root, d *RootDiff) []jsonpatch.Operation {
+	var ops []jsonpatch.Operation
+	if d.Item != nil {
+		ops = append(ops, ItemJSONPatchOps(prefix+"/Item", o.Item, n.Item, d.Item)...)
+	}
+	return ops
+}
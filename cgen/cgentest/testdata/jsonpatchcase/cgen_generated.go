@@ -0,0 +1,298 @@
+// Code generated by go generate; DO NOT EDIT.
+package jsonpatchcase
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/juliangracin/svckit/cgen"
+	"github.com/juliangracin/svckit/cgen/jsonpatch"
+)
+
+type InfoDiff struct {
+	Age *int
+}
+
+type TagDiff struct {
+	V *string
+}
+
+type RootDiff struct {
+	Info *InfoDiff
+	Tags TagDiffMap
+}
+
+type TagDiffMap map[string]*TagDiff
+
+func (m *TagDiffMap) Set(key string, value *TagDiff) *TagDiff {
+	if *m == nil {
+		*m = make(map[string]*TagDiff)
+	}
+	mv := *m
+	mv[key] = value
+	return value
+}
+func (m *TagDiffMap) Nil(key string) {
+	m.Set(key, nil)
+}
+func (m *TagDiffMap) Empty(key string) *TagDiff {
+	return m.Set(key, &TagDiff{})
+}
+
+func (o Info) merge(d *InfoDiff, mo *cgen.MergeOptions) (Info, bool) {
+	if d == nil {
+		return o, false
+	}
+	changed := false
+	// fields
+	if d.Age != nil {
+		if fn, ok := mo.Transformer(reflect.TypeOf(o.Age)); ok {
+			if err := fn(&o.Age, *d.Age); err == nil {
+				changed = true
+			}
+		} else {
+			var zeroAge int
+			if (mo.OverwriteEmpty() || *d.Age != zeroAge) && *d.Age != o.Age {
+				o.Age = *d.Age
+				changed = true
+			}
+		}
+	}
+	return o, changed
+}
+
+func (o Tag) merge(d *TagDiff, mo *cgen.MergeOptions) (Tag, bool) {
+	if d == nil {
+		return o, false
+	}
+	changed := false
+	// fields
+	if d.V != nil {
+		if fn, ok := mo.Transformer(reflect.TypeOf(o.V)); ok {
+			if err := fn(&o.V, *d.V); err == nil {
+				changed = true
+			}
+		} else {
+			var zeroV string
+			if (mo.OverwriteEmpty() || *d.V != zeroV) && *d.V != o.V {
+				o.V = *d.V
+				changed = true
+			}
+		}
+	}
+	return o, changed
+}
+
+// Merge applies diff (d) to Root (o), configured by opts (see
+// WithOverride, WithoutOverwriteEmpty, WithAppendSlice, WithoutMapDelete,
+// WithTypeTransformer), and returns new value type with merged changes.
+// Doesn't modifies original value (o).
+func (o Root) Merge(d RootDiff, opts ...cgen.MergeOption) Root {
+	n, _ := o.merge(&d, cgen.NewMergeOptions(opts...))
+	return n
+}
+
+func (o Root) merge(d *RootDiff, mo *cgen.MergeOptions) (Root, bool) {
+	if d == nil {
+		return o, false
+	}
+	changed := false
+	// fields
+	// Info field
+	if o2, merged := o.Info.merge(d.Info, mo); merged {
+		o.Info = o2
+		changed = true
+	}
+	// Tags map
+	var copyTagsOnce sync.Once
+	copyOnWriteTags := func() {
+		copyTagsOnce.Do(func() {
+			m := make(map[string]Tag)
+			for k, v := range o.Tags {
+				m[k] = v
+			}
+			o.Tags = m
+			changed = true
+		})
+	}
+	for k, dc := range d.Tags {
+		c, ok := o.Tags[k]
+		if dc == nil {
+			if ok && mo.MapDelete() {
+				copyOnWriteTags()
+				delete(o.Tags, k)
+			}
+			continue
+		}
+		if c2, merged := c.merge(dc, mo); merged || !ok {
+			copyOnWriteTags()
+			o.Tags[k] = c2
+		}
+	}
+	return o, changed
+}
+
+func (o Info) diff(n Info) *InfoDiff {
+	i := &InfoDiff{}
+	if n.Age != o.Age {
+		i.Age = &n.Age
+	}
+	if i.empty() {
+		return nil
+	}
+	return i
+}
+
+func (i InfoDiff) empty() bool {
+	return i.Age == nil
+}
+
+func (o Tag) diff(n Tag) *TagDiff {
+	i := &TagDiff{}
+	if n.V != o.V {
+		i.V = &n.V
+	}
+	if i.empty() {
+		return nil
+	}
+	return i
+}
+
+func (i TagDiff) empty() bool {
+	return i.V == nil
+}
+
+// Diff creates diff (i) between new (n) and old (o) Root.
+// So that diff applyed to old will produce new.
+func (o Root) Diff(n Root) *RootDiff {
+	return o.diff(n)
+}
+
+func (o Root) diff(n Root) *RootDiff {
+	i := &RootDiff{}
+	i.Info = o.Info.diff(n.Info)
+	i.Tags = make(map[string]*TagDiff)
+	for k, nc := range n.Tags {
+		oc, ok := o.Tags[k]
+		if !ok {
+			oc = Tag{}
+		}
+		ip := oc.diff(nc)
+		if ip != nil {
+			i.Tags[k] = ip
+		} else if !ok {
+			// k is a brand-new key whose value happens to equal the zero
+			// value, so oc.diff(nc) found no field-level change; still
+			// record the key as added, or merge would never add it.
+			i.Tags[k] = &TagDiff{}
+		}
+	}
+
+	for k, _ := range o.Tags {
+		if _, ok := n.Tags[k]; !ok {
+			i.Tags[k] = nil
+		}
+	}
+
+	if len(i.Tags) == 0 {
+		i.Tags = nil
+	}
+	if i.empty() {
+		return nil
+	}
+	return i
+}
+
+func (i RootDiff) empty() bool {
+	return i.Info == nil &&
+		i.Tags == nil
+}
+
+func (o Info) Copy() Info {
+	return o
+}
+
+func (o Tag) Copy() Tag {
+	return o
+}
+
+func (o Root) Copy() Root {
+	copyTags := make(map[string]Tag)
+	for k, v := range o.Tags {
+		copyTags[k] = v.Copy()
+	}
+	o.Tags = copyTags
+	return o
+}
+
+// InfoJSONPatchOps flattens d (the diff between o and n) into RFC
+// 6902 operations rooted at prefix.
+func InfoJSONPatchOps(prefix string, o, n Info, d *InfoDiff) []jsonpatch.Operation {
+	var ops []jsonpatch.Operation
+	if d.Age != nil {
+		ops = append(ops, jsonpatch.Operation{Op: jsonpatch.OpReplace, Path: prefix + "/Age", Value: *d.Age})
+	}
+	return ops
+}
+
+// TagJSONPatchOps flattens d (the diff between o and n) into RFC
+// 6902 operations rooted at prefix.
+func TagJSONPatchOps(prefix string, o, n Tag, d *TagDiff) []jsonpatch.Operation {
+	var ops []jsonpatch.Operation
+	if d.V != nil {
+		ops = append(ops, jsonpatch.Operation{Op: jsonpatch.OpReplace, Path: prefix + "/V", Value: *d.V})
+	}
+	return ops
+}
+
+// JSONPatch returns an RFC 6902 JSON Patch turning o into n, built by
+// walking the same diff as Diff and flattening it into JSON Pointer
+// operations.
+func (o Root) JSONPatch(n Root) []jsonpatch.Operation {
+	d := o.diff(n)
+	if d == nil {
+		return nil
+	}
+	return RootJSONPatchOps("", o, n, d)
+}
+
+// ApplyJSONPatch applies p to o and returns the result.
+func (o Root) ApplyJSONPatch(p []jsonpatch.Operation) (Root, error) {
+	doc, err := json.Marshal(o)
+	if err != nil {
+		return o, fmt.Errorf("marshaling Root: %w", err)
+	}
+	patched, err := jsonpatch.Apply(doc, p)
+	if err != nil {
+		return o, fmt.Errorf("applying json patch to Root: %w", err)
+	}
+	var n Root
+	if err := json.Unmarshal(patched, &n); err != nil {
+		return o, fmt.Errorf("unmarshaling patched Root: %w", err)
+	}
+	return n, nil
+}
+
+// RootJSONPatchOps flattens d (the diff between o and n) into RFC
+// 6902 operations rooted at prefix.
+func RootJSONPatchOps(prefix string, o, n Root, d *RootDiff) []jsonpatch.Operation {
+	var ops []jsonpatch.Operation
+	if d.Info != nil {
+		ops = append(ops, InfoJSONPatchOps(prefix+"/Info", o.Info, n.Info, d.Info)...)
+	}
+	for k, dc := range d.Tags {
+		path := fmt.Sprintf("%s/Tags/%v", prefix, k)
+		if dc == nil {
+			ops = append(ops, jsonpatch.Operation{Op: jsonpatch.OpRemove, Path: path})
+			continue
+		}
+		if oc, ok := o.Tags[k]; ok {
+			ops = append(ops, TagJSONPatchOps(path, oc, n.Tags[k], dc)...)
+		} else {
+			ops = append(ops, jsonpatch.Operation{Op: jsonpatch.OpAdd, Path: path, Value: n.Tags[k]})
+		}
+	}
+	return ops
+}
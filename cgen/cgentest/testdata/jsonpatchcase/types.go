@@ -0,0 +1,19 @@
+// Package jsonpatchcase is a cgentest fixture exercising JSONPatch for
+// untagged fields, in particular that the emitted paths match what
+// encoding/json actually names them (see
+// cgen/cgentest/jsonpatchcase_test.go).
+package jsonpatchcase
+
+type Info struct {
+	Age int
+}
+
+type Tag struct {
+	V string
+}
+
+type Root struct {
+	_    struct{} `cgen:"root"`
+	Info Info
+	Tags map[string]Tag
+}
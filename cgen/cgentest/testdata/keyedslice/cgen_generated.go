@@ -0,0 +1,301 @@
+// Code generated by go generate; DO NOT EDIT.
+package keyedslice
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/juliangracin/svckit/cgen"
+	"github.com/juliangracin/svckit/cgen/jsonpatch"
+)
+
+type ItemDiff struct {
+	ID   *int
+	Name *string
+}
+
+type RootDiff struct {
+	Items *ItemSliceDiff
+}
+
+type ItemSliceOpKind int
+
+const (
+	ItemSliceOpKeep ItemSliceOpKind = iota
+	ItemSliceOpInsert
+	ItemSliceOpDelete
+	ItemSliceOpReplace
+)
+
+// ItemSliceOp is one step of a ItemSliceDiff edit script.
+// Index is the element's position in the new slice (Delete uses the
+// position it was removed from). Old is the element's
+// position in o, used by Keep and Replace so merge reads (and, for
+// Replace, diffs) the right element of o regardless of how Index and Old
+// relate (they can differ for a cgen:"key=..." slice, e.g. on a
+// reorder).
+type ItemSliceOp struct {
+	Kind  ItemSliceOpKind
+	Index int
+	Value *Item
+	Old   int
+	Diff  *ItemDiff
+}
+
+// ItemSliceDiff is a compact edit script turning an old []Item
+// into a new one. Nil distinguishes "slice became nil" (true) from
+// "slice became empty" (false); when Nil is set, Ops is always empty.
+type ItemSliceDiff struct {
+	Nil *bool
+	Ops []ItemSliceOp
+}
+
+func (o Item) merge(d *ItemDiff, mo *cgen.MergeOptions) (Item, bool) {
+	if d == nil {
+		return o, false
+	}
+	changed := false
+	// fields
+	if d.ID != nil {
+		if fn, ok := mo.Transformer(reflect.TypeOf(o.ID)); ok {
+			if err := fn(&o.ID, *d.ID); err == nil {
+				changed = true
+			}
+		} else {
+			var zeroID int
+			if (mo.OverwriteEmpty() || *d.ID != zeroID) && *d.ID != o.ID {
+				o.ID = *d.ID
+				changed = true
+			}
+		}
+	}
+	if d.Name != nil {
+		if fn, ok := mo.Transformer(reflect.TypeOf(o.Name)); ok {
+			if err := fn(&o.Name, *d.Name); err == nil {
+				changed = true
+			}
+		} else {
+			var zeroName string
+			if (mo.OverwriteEmpty() || *d.Name != zeroName) && *d.Name != o.Name {
+				o.Name = *d.Name
+				changed = true
+			}
+		}
+	}
+	return o, changed
+}
+
+// Merge applies diff (d) to Root (o), configured by opts (see
+// WithOverride, WithoutOverwriteEmpty, WithAppendSlice, WithoutMapDelete,
+// WithTypeTransformer), and returns new value type with merged changes.
+// Doesn't modifies original value (o).
+func (o Root) Merge(d RootDiff, opts ...cgen.MergeOption) Root {
+	n, _ := o.merge(&d, cgen.NewMergeOptions(opts...))
+	return n
+}
+
+func (o Root) merge(d *RootDiff, mo *cgen.MergeOptions) (Root, bool) {
+	if d == nil {
+		return o, false
+	}
+	changed := false
+	// fields
+	// Items slice
+	if d.Items != nil {
+		o.Items = mergeItemSlice(o.Items, d.Items, mo)
+		changed = true
+	}
+	return o, changed
+}
+
+func (o Item) diff(n Item) *ItemDiff {
+	i := &ItemDiff{}
+	if n.ID != o.ID {
+		i.ID = &n.ID
+	}
+	if n.Name != o.Name {
+		i.Name = &n.Name
+	}
+	if i.empty() {
+		return nil
+	}
+	return i
+}
+
+func (i ItemDiff) empty() bool {
+	return i.ID == nil &&
+		i.Name == nil
+}
+
+// Diff creates diff (i) between new (n) and old (o) Root.
+// So that diff applyed to old will produce new.
+func (o Root) Diff(n Root) *RootDiff {
+	return o.diff(n)
+}
+
+func (o Root) diff(n Root) *RootDiff {
+	i := &RootDiff{}
+	i.Items = diffItemSlice(o.Items, n.Items)
+	if i.empty() {
+		return nil
+	}
+	return i
+}
+
+func (i RootDiff) empty() bool {
+	return i.Items == nil
+}
+
+func (o Item) Copy() Item {
+	return o
+}
+
+func (o Root) Copy() Root {
+	if o.Items != nil {
+		copyItems := make([]Item, len(o.Items))
+		for i, v := range o.Items {
+			copyItems[i] = v.Copy()
+		}
+		o.Items = copyItems
+	}
+	return o
+}
+
+func diffItemSlice(o, n []Item) *ItemSliceDiff {
+	if o == nil && n == nil {
+		return nil
+	}
+	if (o == nil) != (n == nil) && len(n) == 0 {
+		isNil := n == nil
+		return &ItemSliceDiff{Nil: &isNil}
+	}
+	ops := ItemSliceOps(o, n)
+	if len(ops) == 0 {
+		return nil
+	}
+	return &ItemSliceDiff{Ops: ops}
+}
+
+// ItemSliceOps matches elements between o and n by ID,
+// so a reordered or modified element is diffed in place instead of
+// being deleted and reinserted.
+func ItemSliceOps(o, n []Item) []ItemSliceOp {
+	oIdx := make(map[interface{}]int, len(o))
+	for idx, v := range o {
+		oIdx[v.ID] = idx
+	}
+	seen := make(map[interface{}]bool, len(o))
+
+	var ops []ItemSliceOp
+	for j, nv := range n {
+		seen[nv.ID] = true
+		idx, ok := oIdx[nv.ID]
+		if !ok {
+			ops = append(ops, ItemSliceOp{Kind: ItemSliceOpInsert, Index: j, Value: &n[j]})
+			continue
+		}
+		if d := o[idx].diff(nv); d != nil {
+			ops = append(ops, ItemSliceOp{Kind: ItemSliceOpReplace, Index: j, Old: idx, Value: &n[j], Diff: d})
+		} else {
+			ops = append(ops, ItemSliceOp{Kind: ItemSliceOpKeep, Index: j, Old: idx, Value: &n[j]})
+		}
+	}
+	for idx, ov := range o {
+		if !seen[ov.ID] {
+			ops = append(ops, ItemSliceOp{Kind: ItemSliceOpDelete, Index: idx, Old: idx})
+		}
+	}
+	return ops
+}
+
+// mergeItemSlice rebuilds a new []Item from d.Ops, leaving o
+// untouched. Keep and Replace read o[op.Old], the
+// element's position in o, rather than the old slice the diff was
+// computed from, so merge leaves an unchanged element as o currently
+// has it (mirroring scalar fields: a nil diff means "don't touch it")
+// and still merges the right element after a reorder. Insert uses the op's own Value.
+// With WithAppendSlice, inserts are appended regardless of their
+// recorded index and deletes/replaces are left in place.
+func mergeItemSlice(o []Item, d *ItemSliceDiff, mo *cgen.MergeOptions) []Item {
+	if d.Nil != nil {
+		if *d.Nil {
+			return nil
+		}
+		return []Item{}
+	}
+	if mo.AppendSlice() {
+		n := append([]Item{}, o...)
+		for _, op := range d.Ops {
+			if op.Kind == ItemSliceOpInsert {
+				n = append(n, *op.Value)
+			}
+		}
+		return n
+	}
+	n := make([]Item, 0, len(o)+len(d.Ops))
+	for _, op := range d.Ops {
+		switch op.Kind {
+		case ItemSliceOpKeep:
+			n = append(n, o[op.Old])
+		case ItemSliceOpDelete:
+		case ItemSliceOpInsert:
+			n = append(n, *op.Value)
+		case ItemSliceOpReplace:
+			v, _ := o[op.Old].merge(op.Diff, mo)
+			n = append(n, v)
+		}
+	}
+	return n
+}
+
+// ItemJSONPatchOps flattens d (the diff between o and n) into RFC
+// 6902 operations rooted at prefix.
+func ItemJSONPatchOps(prefix string, o, n Item, d *ItemDiff) []jsonpatch.Operation {
+	var ops []jsonpatch.Operation
+	if d.ID != nil {
+		ops = append(ops, jsonpatch.Operation{Op: jsonpatch.OpReplace, Path: prefix + "/id", Value: *d.ID})
+	}
+	if d.Name != nil {
+		ops = append(ops, jsonpatch.Operation{Op: jsonpatch.OpReplace, Path: prefix + "/name", Value: *d.Name})
+	}
+	return ops
+}
+
+// JSONPatch returns an RFC 6902 JSON Patch turning o into n, built by
+// walking the same diff as Diff and flattening it into JSON Pointer
+// operations.
+func (o Root) JSONPatch(n Root) []jsonpatch.Operation {
+	d := o.diff(n)
+	if d == nil {
+		return nil
+	}
+	return RootJSONPatchOps("", o, n, d)
+}
+
+// ApplyJSONPatch applies p to o and returns the result.
+func (o Root) ApplyJSONPatch(p []jsonpatch.Operation) (Root, error) {
+	doc, err := json.Marshal(o)
+	if err != nil {
+		return o, fmt.Errorf("marshaling Root: %w", err)
+	}
+	patched, err := jsonpatch.Apply(doc, p)
+	if err != nil {
+		return o, fmt.Errorf("applying json patch to Root: %w", err)
+	}
+	var n Root
+	if err := json.Unmarshal(patched, &n); err != nil {
+		return o, fmt.Errorf("unmarshaling patched Root: %w", err)
+	}
+	return n, nil
+}
+
+// RootJSONPatchOps flattens d (the diff between o and n) into RFC
+// 6902 operations rooted at prefix.
+func RootJSONPatchOps(prefix string, o, n Root, d *RootDiff) []jsonpatch.Operation {
+	var ops []jsonpatch.Operation
+	if d.Items != nil {
+		ops = append(ops, jsonpatch.Operation{Op: jsonpatch.OpReplace, Path: prefix + "/items", Value: n.Items})
+	}
+	return ops
+}
@@ -0,0 +1,14 @@
+// Package keyedslice is a cgentest fixture exercising a cgen:"key=..."
+// slice, in particular that merge round-trips a reorder and a keyed
+// modify (see cgen/cgentest/keyedslice_test.go).
+package keyedslice
+
+type Item struct {
+	ID   int
+	Name string
+}
+
+type Root struct {
+	_     struct{} `cgen:"root"`
+	Items []Item   `cgen:"key=ID"`
+}
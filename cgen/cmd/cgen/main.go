@@ -0,0 +1,43 @@
+// Command cgen generates Diff/Merge/Copy code for a struct type, meant to
+// be invoked from a //go:generate directive, e.g.
+//
+//	//go:generate go run github.com/juliangracin/svckit/cgen/cmd/cgen -type Root
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/juliangracin/svckit/cgen/loader"
+)
+
+func main() {
+	typeName := flag.String("type", "", "name of the root struct type to generate Diff/Merge/Copy code for; if empty, the type with a `cgen:\"root\"` field is used")
+	out := flag.String("out", "cgen_generated.go", "output file name")
+	flag.Parse()
+
+	pkgPath := "."
+	if dir := os.Getenv("GOPACKAGE_DIR"); dir != "" {
+		pkgPath = dir
+	}
+
+	if err := run(pkgPath, *typeName, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "cgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(pkgPath, typeName, out string) error {
+	data, err := loader.Load(pkgPath, typeName)
+	if err != nil {
+		return fmt.Errorf("loading %s: %w", typeName, err)
+	}
+
+	code, err := data.Diff()
+	if err != nil {
+		return fmt.Errorf("generating %s: %w", typeName, err)
+	}
+
+	return code.Save(out)
+}
@@ -3,13 +3,35 @@ package cgen
 import (
 	"bytes"
 	"fmt"
+	"go/format"
 	"io/ioutil"
-	"log"
-	"os/exec"
 	"strings"
 	"text/template"
+
+	"golang.org/x/tools/imports"
 )
 
+// FormatMode selects how generated source is formatted before it is
+// returned from Data.Diff.
+type FormatMode int
+
+const (
+	// FormatGoimports runs go/format and then fixes up the import block,
+	// matching the historical gofmt+goimports pipeline. This is the
+	// default used by Data.Diff.
+	FormatGoimports FormatMode = iota
+	// FormatGofmt only runs go/format, leaving imports untouched.
+	FormatGofmt
+	// FormatRaw skips formatting entirely and returns the generated
+	// source as-is.
+	FormatRaw
+)
+
+// Options controls how Data.DiffWithOptions formats the generated code.
+type Options struct {
+	Format FormatMode
+}
+
 type Code struct {
 	content []byte
 }
@@ -22,64 +44,127 @@ func (c Code) Bytes() []byte {
 	return c.content
 }
 
-func (c Code) Save(filename string) {
-	err := ioutil.WriteFile(filename, c.content, 0644)
-	if err != nil {
-		log.Fatal(err)
+// Save writes the generated code to filename.
+func (c Code) Save(filename string) error {
+	if err := ioutil.WriteFile(filename, c.content, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", filename, err)
 	}
-	fmt.Printf("generated %s\n", filename)
+	return nil
+}
+
+// Diff generates the typed Diff/Merge/Copy code for data, formatting it
+// with goimports (FormatGoimports).
+func (data Data) Diff() (Code, error) {
+	return data.DiffWithOptions(Options{Format: FormatGoimports})
 }
 
-func (data Data) Diff() Code {
+// DiffWithOptions generates the typed Diff/Merge/Copy code for data, using
+// opts to control formatting.
+func (data Data) DiffWithOptions(opts Options) (Code, error) {
 	buf := bytes.NewBuffer(nil)
 	buf.WriteString(fmt.Sprintf("// Code generated by go generate; DO NOT EDIT.\npackage %s \n", data.Package))
-	buf.Write(data.diff())
-	buf.Write(data.merge())
-	buf.Write(data.diffMethods())
-	buf.Write(data.copyMethods())
-	return Code{content: gofmt(buf.Bytes())}
+
+	diff, err := data.diff()
+	if err != nil {
+		return Code{}, err
+	}
+	merge, err := data.merge()
+	if err != nil {
+		return Code{}, err
+	}
+	diffMethods, err := data.diffMethods()
+	if err != nil {
+		return Code{}, err
+	}
+	copyMethods, err := data.copyMethods()
+	if err != nil {
+		return Code{}, err
+	}
+	sliceMethods, err := data.sliceMethods()
+	if err != nil {
+		return Code{}, err
+	}
+	jsonPatchMethods, err := data.jsonPatchMethods()
+	if err != nil {
+		return Code{}, err
+	}
+	buf.Write(diff)
+	buf.Write(merge)
+	buf.Write(diffMethods)
+	buf.Write(copyMethods)
+	buf.Write(sliceMethods)
+	buf.Write(jsonPatchMethods)
+
+	content, err := formatSource(buf.Bytes(), opts.Format)
+	if err != nil {
+		return Code{}, fmt.Errorf("gofmt failed on %sDiff: %w", data.rootType(), err)
+	}
+	return Code{content: content}, nil
+}
+
+// rootType returns the Type of data's root Struct, for use in messages.
+func (data Data) rootType() string {
+	for _, st := range data.Structs {
+		if st.IsRoot {
+			return st.Type
+		}
+	}
+	return data.Package
 }
 
-func (data Data) diff() []byte {
+func (data Data) diff() ([]byte, error) {
 	return runTemplate(diffTemplate, data)
 }
 
-func (data Data) merge() []byte {
+func (data Data) merge() ([]byte, error) {
 	return runTemplate(mergeTemplate, data)
 }
 
-func (data Data) diffMethods() []byte {
+func (data Data) diffMethods() ([]byte, error) {
 	return runTemplate(diffMethodsTemplate, data)
 }
 
-func (data Data) copyMethods() []byte {
+func (data Data) copyMethods() ([]byte, error) {
 	return runTemplate(copyMethods, data)
 }
 
-func runTemplate(tplDef string, data interface{}) []byte {
+func (data Data) sliceMethods() ([]byte, error) {
+	return runTemplate(sliceMethodsTemplate, data)
+}
+
+func (data Data) jsonPatchMethods() ([]byte, error) {
+	return runTemplate(jsonPatchMethodsTemplate, data)
+}
+
+func runTemplate(tplDef string, data interface{}) ([]byte, error) {
 	fcs := template.FuncMap{"join": strings.Join}
 	tpl := template.Must(template.New("").Funcs(fcs).Parse(tplDef))
 	buf := bytes.NewBuffer(nil)
 	if err := tpl.Execute(buf, data); err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("executing template: %w", err)
 	}
-	return buf.Bytes()
+	return buf.Bytes(), nil
 }
 
-func gofmt(in []byte) []byte {
-	cmd := exec.Command("gofmt")
-	cmd.Stdin = strings.NewReader(string(in))
-	out, err := cmd.Output()
+// formatSource formats in according to mode, using go/format and
+// golang.org/x/tools/imports in-process (no gofmt/goimports binaries
+// required on $PATH).
+func formatSource(in []byte, mode FormatMode) ([]byte, error) {
+	if mode == FormatRaw {
+		return in, nil
+	}
+	out, err := format.Source(in)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	cmd = exec.Command("goimports")
-	cmd.Stdin = strings.NewReader(string(out))
-	out, err = cmd.Output()
+	if mode == FormatGofmt {
+		return out, nil
+	}
+	out, err = imports.Process("", out, nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	return out
+	return out, nil
 }
 
 var diffTemplate = `
@@ -94,8 +179,47 @@ type {{.Type}}Diff struct {
 {{- range .Maps}}
 	{{.Field}} {{.Value}}DiffMap {{.Tag}}
 {{- end}}
+{{- range .Slices}}
+	{{.Field}} *{{.Elem}}SliceDiff {{.Tag}}
+{{- end}}
 }
 
+{{ range .Slices}}
+type {{.Elem}}SliceOpKind int
+
+const (
+	{{.Elem}}SliceOpKeep {{.Elem}}SliceOpKind = iota
+	{{.Elem}}SliceOpInsert
+	{{.Elem}}SliceOpDelete
+	{{.Elem}}SliceOpReplace
+)
+
+// {{.Elem}}SliceOp is one step of a {{.Elem}}SliceDiff edit script.
+// Index is the element's position in the new slice (Delete uses the
+// position it was removed from).{{if .IsStruct}} Old is the element's
+// position in o, used by Keep and Replace so merge reads (and, for
+// Replace, diffs) the right element of o regardless of how Index and Old
+// relate (they can differ for a cgen:"key=..." slice, e.g. on a
+// reorder).{{end}}
+type {{.Elem}}SliceOp struct {
+	Kind  {{.Elem}}SliceOpKind
+	Index int
+	Value *{{.Elem}}
+	{{- if .IsStruct}}
+	Old   int
+	Diff  *{{.Elem}}Diff
+	{{- end}}
+}
+
+// {{.Elem}}SliceDiff is a compact edit script turning an old []{{.Elem}}
+// into a new one. Nil distinguishes "slice became nil" (true) from
+// "slice became empty" (false); when Nil is set, Ops is always empty.
+type {{.Elem}}SliceDiff struct {
+	Nil *bool
+	Ops []{{.Elem}}SliceOp
+}
+{{- end}}
+
 {{ range .Maps}}
 type {{.Value}}DiffMap map[{{.Key}}]*{{.Value}}Diff
 func (m *{{.Value}}DiffMap) Set(key {{.Key}}, value *{{.Value}}Diff) *{{.Value}}Diff {
@@ -120,36 +244,54 @@ var mergeTemplate = `
 {{- range .Structs }}
 
 {{- if .IsRoot}}
-// Merge applies diff (d) to {{.Type}} (o)
-// and returns new value type with merged changes.
+// Merge applies diff (d) to {{.Type}} (o), configured by opts (see
+// WithOverride, WithoutOverwriteEmpty, WithAppendSlice, WithoutMapDelete,
+// WithTypeTransformer), and returns new value type with merged changes.
 // Doesn't modifies original value (o).
-func (o {{.Type}}) Merge(d {{.Type}}Diff) {{.Type}} {
-  n, _ := o.merge(&d)
+func (o {{.Type}}) Merge(d {{.Type}}Diff, opts ...cgen.MergeOption) {{.Type}} {
+  n, _ := o.merge(&d, cgen.NewMergeOptions(opts...))
   return n
 }
 {{- end}}
 
-func (o {{.Type}}) merge(d *{{.Type}}Diff) ({{.Type}}, bool) {
+func (o {{.Type}}) merge(d *{{.Type}}Diff, mo *cgen.MergeOptions) ({{.Type}}, bool) {
   if d == nil {
     return o, false
   }
   changed := false
 // fields
 {{- range .Fields }}
-  if d.{{.Name}} != nil && *d.{{.Name}} != o.{{.Name}} {
-		o.{{.Name}} = *d.{{.Name}}
-    changed = true
-	}
+  if d.{{.Name}} != nil {
+    if fn, ok := mo.Transformer(reflect.TypeOf(o.{{.Name}})); ok {
+      if err := fn(&o.{{.Name}}, *d.{{.Name}}); err == nil {
+        changed = true
+      }
+    } else {
+      var zero{{.Name}} {{.Type}}
+      if (mo.OverwriteEmpty() || *d.{{.Name}} != zero{{.Name}}) && *d.{{.Name}} != o.{{.Name}} {
+		    o.{{.Name}} = *d.{{.Name}}
+        changed = true
+	    }
+    }
+  }
 {{- end}}
 
 {{- range .StructFields}}
   // {{.Name}} field
-  if o2, merged := o.{{.Name}}.merge(d.{{.Name}}); merged {
+  if o2, merged := o.{{.Name}}.merge(d.{{.Name}}, mo); merged {
     o.{{.Name}} = o2
     changed = true
   }
 {{- end}}
 
+{{- range .Slices}}
+  // {{.Field}} slice
+  if d.{{.Field}} != nil {
+    o.{{.Field}} = merge{{.Elem}}Slice(o.{{.Field}}, d.{{.Field}}, mo)
+    changed = true
+  }
+{{- end}}
+
 {{- range .Maps}}
 // {{.Field}} map
   	var copy{{.Field}}Once sync.Once
@@ -166,13 +308,13 @@ func (o {{.Type}}) merge(d *{{.Type}}Diff) ({{.Type}}, bool) {
 		for k, dc := range d.{{.Field}} {
 			c, ok := o.{{.Field}}[k]
 			if dc == nil {
-				if ok {
+				if ok && mo.MapDelete() {
           copyOnWrite{{.Field}}()
           delete(o.{{.Field}}, k)
 				}
 				continue
 			}
-  		if c2, merged := c.merge(dc); merged {
+  		if c2, merged := c.merge(dc, mo); merged || !ok {
     		copyOnWrite{{.Field}}()
   	  	o.{{.Field}}[k] = c2
       }
@@ -205,6 +347,9 @@ func (o {{.Type}}) diff(n {{.Type}}) *{{.Type}}Diff {
 {{- range .StructFields}}
   i.{{.Name}} = o.{{.Name}}.diff(n.{{.Name}})
 {{- end}}
+{{- range .Slices}}
+  i.{{.Field}} = diff{{.Elem}}Slice(o.{{.Field}}, n.{{.Field}})
+{{- end}}
 {{- range .Maps}}
 	i.{{.Field}} = make(map[{{.Key}}]*{{.Value}}Diff)
 	for k, nc := range n.{{.Field}} {
@@ -215,6 +360,11 @@ func (o {{.Type}}) diff(n {{.Type}}) *{{.Type}}Diff {
 		ip := oc.diff(nc)
 		if ip != nil {
 			i.{{.Field}}[k] = ip
+		} else if !ok {
+			// k is a brand-new key whose value happens to equal the zero
+			// value, so oc.diff(nc) found no field-level change; still
+			// record the key as added, or merge would never add it.
+			i.{{.Field}}[k] = &{{.Value}}Diff{}
 		}
 	}
 
@@ -250,8 +400,279 @@ func (o {{.Type}}) Copy() {{.Type}} {
       copy{{.Field}}[k] = v.Copy()
     }
     o.{{.Field}} = copy{{.Field}}
+{{- end}}
+{{- range .Slices}}
+{{- if .IsStruct}}
+  if o.{{.Field}} != nil {
+    copy{{.Field}} := make([]{{.Elem}}, len(o.{{.Field}}))
+    for i, v := range o.{{.Field}} {
+      copy{{.Field}}[i] = v.Copy()
+    }
+    o.{{.Field}} = copy{{.Field}}
+  }
+{{- else}}
+  o.{{.Field}} = append([]{{.Elem}}(nil), o.{{.Field}}...)
+{{- end}}
 {{- end}}
   return o
 }
 
 {{- end}}`
+
+// sliceMethodsTemplate generates the per-Elem diff/merge helpers used by
+// the Slices bucket. Scalar elements are aligned with a classic
+// Hunt-McIlroy LCS so unchanged runs turn into Keep ops instead of a
+// wholesale replace; struct elements without a declared key diff
+// positionally, and with a key diff by matching keys between the old and
+// new slice.
+var sliceMethodsTemplate = `
+{{- range .Structs }}
+{{- range .Slices }}
+
+func diff{{.Elem}}Slice(o, n []{{.Elem}}) *{{.Elem}}SliceDiff {
+	if o == nil && n == nil {
+		return nil
+	}
+	if (o == nil) != (n == nil) && len(n) == 0 {
+		isNil := n == nil
+		return &{{.Elem}}SliceDiff{Nil: &isNil}
+	}
+	ops := {{.Elem}}SliceOps(o, n)
+	if len(ops) == 0 {
+		return nil
+	}
+	return &{{.Elem}}SliceDiff{Ops: ops}
+}
+
+{{- if not .IsStruct}}
+
+// {{.Elem}}SliceOps aligns o and n with their longest common
+// subsequence, so elements that didn't move or change become Keep ops.
+func {{.Elem}}SliceOps(o, n []{{.Elem}}) []{{.Elem}}SliceOp {
+	lcs := make([][]int, len(o)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(n)+1)
+	}
+	for i := len(o) - 1; i >= 0; i-- {
+		for j := len(n) - 1; j >= 0; j-- {
+			if o[i] == n[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []{{.Elem}}SliceOp
+	i, j := 0, 0
+	for i < len(o) && j < len(n) {
+		switch {
+		case o[i] == n[j]:
+			ops = append(ops, {{.Elem}}SliceOp{Kind: {{.Elem}}SliceOpKeep, Index: j, Value: &n[j]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, {{.Elem}}SliceOp{Kind: {{.Elem}}SliceOpDelete, Index: j})
+			i++
+		default:
+			ops = append(ops, {{.Elem}}SliceOp{Kind: {{.Elem}}SliceOpInsert, Index: j, Value: &n[j]})
+			j++
+		}
+	}
+	for ; i < len(o); i++ {
+		ops = append(ops, {{.Elem}}SliceOp{Kind: {{.Elem}}SliceOpDelete, Index: j})
+	}
+	for ; j < len(n); j++ {
+		ops = append(ops, {{.Elem}}SliceOp{Kind: {{.Elem}}SliceOpInsert, Index: j, Value: &n[j]})
+	}
+	return ops
+}
+
+{{- else if .KeyField}}
+
+// {{.Elem}}SliceOps matches elements between o and n by {{.KeyField}},
+// so a reordered or modified element is diffed in place instead of
+// being deleted and reinserted.
+func {{.Elem}}SliceOps(o, n []{{.Elem}}) []{{.Elem}}SliceOp {
+	oIdx := make(map[interface{}]int, len(o))
+	for idx, v := range o {
+		oIdx[v.{{.KeyField}}] = idx
+	}
+	seen := make(map[interface{}]bool, len(o))
+
+	var ops []{{.Elem}}SliceOp
+	for j, nv := range n {
+		seen[nv.{{.KeyField}}] = true
+		idx, ok := oIdx[nv.{{.KeyField}}]
+		if !ok {
+			ops = append(ops, {{.Elem}}SliceOp{Kind: {{.Elem}}SliceOpInsert, Index: j, Value: &n[j]})
+			continue
+		}
+		if d := o[idx].diff(nv); d != nil {
+			ops = append(ops, {{.Elem}}SliceOp{Kind: {{.Elem}}SliceOpReplace, Index: j, Old: idx, Value: &n[j], Diff: d})
+		} else {
+			ops = append(ops, {{.Elem}}SliceOp{Kind: {{.Elem}}SliceOpKeep, Index: j, Old: idx, Value: &n[j]})
+		}
+	}
+	for idx, ov := range o {
+		if !seen[ov.{{.KeyField}}] {
+			ops = append(ops, {{.Elem}}SliceOp{Kind: {{.Elem}}SliceOpDelete, Index: idx, Old: idx})
+		}
+	}
+	return ops
+}
+
+{{- else}}
+
+// {{.Elem}}SliceOps compares o and n element by element, since no
+// cgen:"key=..." tag was declared to match elements across positions.
+func {{.Elem}}SliceOps(o, n []{{.Elem}}) []{{.Elem}}SliceOp {
+	var ops []{{.Elem}}SliceOp
+	for i := 0; i < len(o) && i < len(n); i++ {
+		if d := o[i].diff(n[i]); d != nil {
+			ops = append(ops, {{.Elem}}SliceOp{Kind: {{.Elem}}SliceOpReplace, Index: i, Old: i, Value: &n[i], Diff: d})
+		} else {
+			ops = append(ops, {{.Elem}}SliceOp{Kind: {{.Elem}}SliceOpKeep, Index: i, Old: i, Value: &n[i]})
+		}
+	}
+	for i := len(n); i < len(o); i++ {
+		ops = append(ops, {{.Elem}}SliceOp{Kind: {{.Elem}}SliceOpDelete, Index: len(n), Old: i})
+	}
+	for i := len(o); i < len(n); i++ {
+		ops = append(ops, {{.Elem}}SliceOp{Kind: {{.Elem}}SliceOpInsert, Index: i, Value: &n[i]})
+	}
+	return ops
+}
+
+{{- end}}
+
+// merge{{.Elem}}Slice rebuilds a new []{{.Elem}} from d.Ops, leaving o
+// untouched.{{if .IsStruct}} Keep and Replace read o[op.Old], the
+// element's position in o, rather than the old slice the diff was
+// computed from, so merge leaves an unchanged element as o currently
+// has it (mirroring scalar fields: a nil diff means "don't touch it")
+// and still merges the right element after a reorder.{{else}} Keep uses
+// the op's own Value, which by construction already equals the
+// corresponding element of o.{{end}} Insert uses the op's own Value.
+// With WithAppendSlice, inserts are appended regardless of their
+// recorded index and deletes/replaces are left in place.
+func merge{{.Elem}}Slice(o []{{.Elem}}, d *{{.Elem}}SliceDiff, mo *cgen.MergeOptions) []{{.Elem}} {
+	if d.Nil != nil {
+		if *d.Nil {
+			return nil
+		}
+		return []{{.Elem}}{}
+	}
+	if mo.AppendSlice() {
+		n := append([]{{.Elem}}{}, o...)
+		for _, op := range d.Ops {
+			if op.Kind == {{.Elem}}SliceOpInsert {
+				n = append(n, *op.Value)
+			}
+		}
+		return n
+	}
+	n := make([]{{.Elem}}, 0, len(o)+len(d.Ops))
+	for _, op := range d.Ops {
+		switch op.Kind {
+		case {{.Elem}}SliceOpKeep:
+		{{- if .IsStruct}}
+			n = append(n, o[op.Old])
+		{{- else}}
+			n = append(n, *op.Value)
+		{{- end}}
+		case {{.Elem}}SliceOpDelete:
+		case {{.Elem}}SliceOpInsert:
+			n = append(n, *op.Value)
+		case {{.Elem}}SliceOpReplace:
+		{{- if .IsStruct}}
+			v, _ := o[op.Old].merge(op.Diff, mo)
+			n = append(n, v)
+		{{- else}}
+			n = append(n, *op.Value)
+		{{- end}}
+		}
+	}
+	return n
+}
+{{- end}}
+{{- end}}
+`
+
+// jsonPatchMethodsTemplate generates, for the root type, JSONPatch and
+// ApplyJSONPatch, plus an exported <Type>JSONPatchOps per Struct that
+// flattens its typed Diff tree into RFC 6902 operations. JSONPatch stays
+// consistent with the typed Diff because it's built from the same
+// diff/o/n walk; ApplyJSONPatch round-trips through encoding/json so it
+// works for patches produced by non-Go clients too.
+var jsonPatchMethodsTemplate = `
+{{- range .Structs }}
+{{- if .IsRoot}}
+// JSONPatch returns an RFC 6902 JSON Patch turning o into n, built by
+// walking the same diff as Diff and flattening it into JSON Pointer
+// operations.
+func (o {{.Type}}) JSONPatch(n {{.Type}}) []jsonpatch.Operation {
+	d := o.diff(n)
+	if d == nil {
+		return nil
+	}
+	return {{.Type}}JSONPatchOps("", o, n, d)
+}
+
+// ApplyJSONPatch applies p to o and returns the result.
+func (o {{.Type}}) ApplyJSONPatch(p []jsonpatch.Operation) ({{.Type}}, error) {
+	doc, err := json.Marshal(o)
+	if err != nil {
+		return o, fmt.Errorf("marshaling {{.Type}}: %w", err)
+	}
+	patched, err := jsonpatch.Apply(doc, p)
+	if err != nil {
+		return o, fmt.Errorf("applying json patch to {{.Type}}: %w", err)
+	}
+	var n {{.Type}}
+	if err := json.Unmarshal(patched, &n); err != nil {
+		return o, fmt.Errorf("unmarshaling patched {{.Type}}: %w", err)
+	}
+	return n, nil
+}
+{{- end}}
+
+// {{.Type}}JSONPatchOps flattens d (the diff between o and n) into RFC
+// 6902 operations rooted at prefix.
+func {{.Type}}JSONPatchOps(prefix string, o, n {{.Type}}, d *{{.Type}}Diff) []jsonpatch.Operation {
+	var ops []jsonpatch.Operation
+{{- range .Fields}}
+	if d.{{.Name}} != nil {
+		ops = append(ops, jsonpatch.Operation{Op: jsonpatch.OpReplace, Path: prefix + "/{{.JSONName}}", Value: *d.{{.Name}}})
+	}
+{{- end}}
+{{- range .StructFields}}
+	if d.{{.Name}} != nil {
+		ops = append(ops, {{.Type}}JSONPatchOps(prefix+"/{{.JSONName}}", o.{{.Name}}, n.{{.Name}}, d.{{.Name}})...)
+	}
+{{- end}}
+{{- range .Maps}}
+	for k, dc := range d.{{.Field}} {
+		path := fmt.Sprintf("%s/{{.JSONName}}/%v", prefix, k)
+		if dc == nil {
+			ops = append(ops, jsonpatch.Operation{Op: jsonpatch.OpRemove, Path: path})
+			continue
+		}
+		if oc, ok := o.{{.Field}}[k]; ok {
+			ops = append(ops, {{.Value}}JSONPatchOps(path, oc, n.{{.Field}}[k], dc)...)
+		} else {
+			ops = append(ops, jsonpatch.Operation{Op: jsonpatch.OpAdd, Path: path, Value: n.{{.Field}}[k]})
+		}
+	}
+{{- end}}
+{{- range .Slices}}
+	if d.{{.Field}} != nil {
+		ops = append(ops, jsonpatch.Operation{Op: jsonpatch.OpReplace, Path: prefix + "/{{.JSONName}}", Value: n.{{.Field}}})
+	}
+{{- end}}
+	return ops
+}
+{{- end}}
+`
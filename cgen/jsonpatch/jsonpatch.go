@@ -0,0 +1,168 @@
+// Package jsonpatch defines the RFC 6902 JSON Patch operation type used
+// by cgen-generated JSONPatch/ApplyJSONPatch methods, and a generic
+// Apply that executes a patch against arbitrary JSON.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Op is an RFC 6902 JSON Patch operation name.
+type Op string
+
+const (
+	OpAdd     Op = "add"
+	OpRemove  Op = "remove"
+	OpReplace Op = "replace"
+)
+
+// Operation is a single RFC 6902 JSON Patch operation. Value is omitted
+// for OpRemove.
+type Operation struct {
+	Op    Op          `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Apply applies ops to doc (a JSON-encoded document) and returns the
+// resulting JSON. It operates on the generic JSON tree, so it works the
+// same whether ops were produced by a generated JSONPatch method or by a
+// non-Go client.
+func Apply(doc []byte, ops []Operation) ([]byte, error) {
+	var tree interface{}
+	if err := json.Unmarshal(doc, &tree); err != nil {
+		return nil, fmt.Errorf("unmarshaling document: %w", err)
+	}
+	for _, op := range ops {
+		tokens, err := splitPointer(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("applying %s %s: %w", op.Op, op.Path, err)
+		}
+		switch op.Op {
+		case OpAdd, OpReplace:
+			tree, err = set(tree, tokens, op.Value, op.Op == OpAdd)
+		case OpRemove:
+			tree, err = remove(tree, tokens)
+		default:
+			err = fmt.Errorf("unsupported op %q", op.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("applying %s %s: %w", op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(tree)
+}
+
+// splitPointer splits an RFC 6901 JSON Pointer into its unescaped
+// tokens. The root pointer ("") splits into zero tokens.
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("path %q must be empty or start with /", pointer)
+	}
+	raw := strings.Split(pointer[1:], "/")
+	tokens := make([]string, len(raw))
+	for i, t := range raw {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// set writes value at tokens. With insert (an "add" op), a final array
+// index inserts value before that index instead of replacing it.
+func set(node interface{}, tokens []string, value interface{}, insert bool) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if n == nil {
+			n = map[string]interface{}{}
+		}
+		child, err := set(n[tokens[0]], tokens[1:], value, insert)
+		if err != nil {
+			return nil, err
+		}
+		n[tokens[0]] = child
+		return n, nil
+	case []interface{}:
+		idx, err := sliceIndex(tokens[0], len(n))
+		if err != nil {
+			return nil, err
+		}
+		if len(tokens) == 1 && insert {
+			if idx > len(n) {
+				return nil, fmt.Errorf("index %d out of range", idx)
+			}
+			n = append(n, nil)
+			copy(n[idx+1:], n[idx:])
+			n[idx] = value
+			return n, nil
+		}
+		if idx >= len(n) {
+			return nil, fmt.Errorf("index %d out of range", idx)
+		}
+		child, err := set(n[idx], tokens[1:], value, insert)
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = child
+		return n, nil
+	default:
+		return nil, fmt.Errorf("cannot index into %T", node)
+	}
+}
+
+func remove(node interface{}, tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("cannot remove the document root")
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(tokens) == 1 {
+			delete(n, tokens[0])
+			return n, nil
+		}
+		child, err := remove(n[tokens[0]], tokens[1:])
+		if err != nil {
+			return nil, err
+		}
+		n[tokens[0]] = child
+		return n, nil
+	case []interface{}:
+		idx, err := sliceIndex(tokens[0], len(n))
+		if err != nil {
+			return nil, err
+		}
+		if idx >= len(n) {
+			return nil, fmt.Errorf("index %d out of range", idx)
+		}
+		if len(tokens) == 1 {
+			return append(n[:idx], n[idx+1:]...), nil
+		}
+		child, err := remove(n[idx], tokens[1:])
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = child
+		return n, nil
+	default:
+		return nil, fmt.Errorf("cannot index into %T", node)
+	}
+}
+
+func sliceIndex(token string, length int) (int, error) {
+	if token == "-" {
+		return length, nil
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return idx, nil
+}
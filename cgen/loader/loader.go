@@ -0,0 +1,281 @@
+// Package loader builds cgen.Data from real Go source, so callers no
+// longer have to hand-build Data/Struct/Field values before calling
+// Data.Diff. It is meant to be driven from a //go:generate line, e.g.
+//
+//	//go:generate go run github.com/juliangracin/svckit/cgen/cmd/cgen -type Root
+package loader
+
+import (
+	"fmt"
+	"go/types"
+	"reflect"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/juliangracin/svckit/cgen"
+)
+
+const tagKey = "cgen"
+const jsonTagKey = "json"
+
+const loadMode = packages.NeedName | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax
+
+// Load type-checks the package at pkgPath and builds a cgen.Data
+// describing rootType and every struct type it transitively references.
+// rootType must name an exported struct declared in that package. If
+// rootType is "", the type with a field tagged `cgen:"root"` is used
+// instead.
+func Load(pkgPath, rootType string) (cgen.Data, error) {
+	cfg := &packages.Config{Mode: loadMode}
+	pkgs, err := packages.Load(cfg, pkgPath)
+	if err != nil {
+		return cgen.Data{}, fmt.Errorf("loading %s: %w", pkgPath, err)
+	}
+	if len(pkgs) != 1 {
+		return cgen.Data{}, fmt.Errorf("loading %s: expected one package, got %d", pkgPath, len(pkgs))
+	}
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return cgen.Data{}, fmt.Errorf("loading %s: %v", pkgPath, pkg.Errors[0])
+	}
+
+	if rootType == "" {
+		rootType, err = discoverRoot(pkg)
+		if err != nil {
+			return cgen.Data{}, fmt.Errorf("loading %s: %w", pkgPath, err)
+		}
+	}
+
+	l := &loader{pkg: pkg, seen: map[string]bool{}}
+	root, ok := l.lookupStruct(rootType)
+	if !ok {
+		return cgen.Data{}, fmt.Errorf("type %s not found in %s", rootType, pkgPath)
+	}
+
+	data := cgen.Data{Package: pkg.Types.Name()}
+	if err := l.addStruct(&data, rootType, root, true); err != nil {
+		return cgen.Data{}, err
+	}
+	return data, nil
+}
+
+// discoverRoot finds the struct type in pkg with a field tagged
+// `cgen:"root"`, e.g.
+//
+//	type Root struct {
+//	  _ struct{} `cgen:"root"`
+//	  ...
+//	}
+func discoverRoot(pkg *packages.Package) (string, error) {
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		named, ok := scope.Lookup(name).Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		st, ok := named.Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			if parseTag(st.Tag(i)).root {
+				return name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf(`no type with a "cgen:\"root\"" field found`)
+}
+
+type loader struct {
+	pkg  *packages.Package
+	seen map[string]bool
+}
+
+func (l *loader) lookupStruct(name string) (*types.Struct, bool) {
+	obj := l.pkg.Types.Scope().Lookup(name)
+	if obj == nil {
+		return nil, false
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return nil, false
+	}
+	st, ok := named.Underlying().(*types.Struct)
+	return st, ok
+}
+
+// addStruct appends typeName (and, recursively, every struct type it
+// references) to data.Structs, classifying each field as scalar, nested
+// struct, map, or slice based on its resolved type.
+func (l *loader) addStruct(data *cgen.Data, typeName string, st *types.Struct, isRoot bool) error {
+	if l.seen[typeName] {
+		return nil
+	}
+	l.seen[typeName] = true
+
+	out := cgen.Struct{Type: typeName, IsRoot: isRoot}
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		tag := parseTag(st.Tag(i))
+		if tag.skip {
+			continue
+		}
+		jsonField := jsonName(field.Name(), st.Tag(i))
+
+		switch t := field.Type().Underlying().(type) {
+		case *types.Slice:
+			elemName, elemStruct, isStruct, err := l.sliceElem(t.Elem())
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", typeName, field.Name(), err)
+			}
+			out.Slices = append(out.Slices, cgen.SliceField{
+				Field:    field.Name(),
+				Elem:     elemName,
+				IsStruct: isStruct,
+				KeyField: tag.key,
+				JSONName: jsonField,
+			})
+			if isStruct {
+				if err := l.addStruct(data, elemName, elemStruct, false); err != nil {
+					return err
+				}
+			}
+		case *types.Map:
+			key, ok := t.Key().(*types.Basic)
+			if !ok {
+				return fmt.Errorf("%s.%s: map key type must be a basic comparable type", typeName, field.Name())
+			}
+			valueName, valueStruct, err := l.namedStruct(t.Elem())
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", typeName, field.Name(), err)
+			}
+			out.Maps = append(out.Maps, cgen.Map{
+				Field:    field.Name(),
+				Key:      key.Name(),
+				Value:    valueName,
+				JSONName: jsonField,
+			})
+			if err := l.addStruct(data, valueName, valueStruct, false); err != nil {
+				return err
+			}
+		case *types.Struct:
+			valueName, valueStruct, err := l.namedStruct(field.Type())
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", typeName, field.Name(), err)
+			}
+			out.StructFields = append(out.StructFields, cgen.StructField{Name: field.Name(), Type: valueName, JSONName: jsonField})
+			if err := l.addStruct(data, valueName, valueStruct, false); err != nil {
+				return err
+			}
+		default:
+			out.Fields = append(out.Fields, cgen.Field{Name: field.Name(), Type: l.typeString(field.Type()), JSONName: jsonField})
+		}
+	}
+	out.NilConditions = nilConditions(out)
+	data.Structs = append(data.Structs, out)
+	return nil
+}
+
+// nilConditions builds the "i.Field == nil" expressions used by the
+// generated <Type>Diff.empty method, one per field the diff can carry a
+// change in.
+func nilConditions(st cgen.Struct) []string {
+	var conds []string
+	for _, f := range st.Fields {
+		conds = append(conds, fmt.Sprintf("i.%s == nil", f.Name))
+	}
+	for _, f := range st.StructFields {
+		conds = append(conds, fmt.Sprintf("i.%s == nil", f.Name))
+	}
+	for _, f := range st.Maps {
+		conds = append(conds, fmt.Sprintf("i.%s == nil", f.Field))
+	}
+	for _, f := range st.Slices {
+		conds = append(conds, fmt.Sprintf("i.%s == nil", f.Field))
+	}
+	if len(conds) == 0 {
+		conds = []string{"true"}
+	}
+	return conds
+}
+
+// typeString renders t the way it should appear in generated source: the
+// local name for types declared in the package being loaded, and a
+// package-qualified name (e.g. "time.Time") for types declared elsewhere,
+// which goimports then resolves to the matching import.
+func (l *loader) typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == l.pkg.Types {
+			return ""
+		}
+		return p.Name()
+	})
+}
+
+// sliceElem resolves a slice element type to its Go type name and,
+// if it's a struct, its underlying type.
+func (l *loader) sliceElem(t types.Type) (name string, st *types.Struct, isStruct bool, err error) {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return l.typeString(t), nil, false, nil
+	}
+	st, ok = named.Underlying().(*types.Struct)
+	if !ok {
+		return named.Obj().Name(), nil, false, nil
+	}
+	return named.Obj().Name(), st, true, nil
+}
+
+// namedStruct resolves t to its declared name and underlying struct type.
+// Anonymous (unnamed) struct types aren't supported, since the generated
+// code needs a name to hang a <Type>Diff type off of.
+func (l *loader) namedStruct(t types.Type) (string, *types.Struct, error) {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return "", nil, fmt.Errorf("anonymous struct types are not supported, declare a named type")
+	}
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return "", nil, fmt.Errorf("%s is not a struct", named.Obj().Name())
+	}
+	return named.Obj().Name(), st, nil
+}
+
+// jsonName returns the field's JSON Pointer token: its `json:"..."` tag
+// name, or its Go name verbatim if no tag (or a "-" or empty tag) is
+// set, matching what encoding/json emits for an untagged field.
+func jsonName(fieldName, tag string) string {
+	raw := reflect.StructTag(tag).Get(jsonTagKey)
+	name := strings.Split(raw, ",")[0]
+	if name == "" || name == "-" {
+		return fieldName
+	}
+	return name
+}
+
+type cgenTag struct {
+	skip bool
+	key  string
+	root bool
+}
+
+// parseTag reads the `cgen:"..."` struct tag. Recognized values are "-"
+// (skip the field entirely), "key=<Field>" (use <Field> to identify
+// slice elements for keyed diffing, see cgen.SliceField), and "root"
+// (mark the enclosing type as the default root for Load, see
+// discoverRoot; the tagged field itself is skipped like "-").
+func parseTag(tag string) cgenTag {
+	raw := reflect.StructTag(tag).Get(tagKey)
+	if raw == "-" {
+		return cgenTag{skip: true}
+	}
+	if raw == "root" {
+		return cgenTag{skip: true, root: true}
+	}
+	const keyPrefix = "key="
+	if len(raw) > len(keyPrefix) && raw[:len(keyPrefix)] == keyPrefix {
+		return cgenTag{key: raw[len(keyPrefix):]}
+	}
+	return cgenTag{}
+}
@@ -0,0 +1,83 @@
+package cgen
+
+import "reflect"
+
+// MergeOptions holds the resolved settings for one generated Merge call.
+// Generated code only reads it through the accessor methods below;
+// construct one with NewMergeOptions.
+type MergeOptions struct {
+	override       bool
+	overwriteEmpty bool
+	appendSlice    bool
+	mapDelete      bool
+	transformers   map[reflect.Type]func(dst, src interface{}) error
+}
+
+// MergeOption configures a MergeOptions. NewMergeOptions called with no
+// options matches the generated merge's historical, unconfigured behavior:
+// scalars and map entries are always overwritten, and slice diffs are
+// applied positionally.
+type MergeOption func(*MergeOptions)
+
+// NewMergeOptions builds a MergeOptions from opts. Generated Merge
+// methods call this once and thread the result through every nested
+// merge call.
+func NewMergeOptions(opts ...MergeOption) *MergeOptions {
+	mo := &MergeOptions{overwriteEmpty: true, mapDelete: true}
+	for _, opt := range opts {
+		opt(mo)
+	}
+	return mo
+}
+
+// WithOverride mirrors mergo's WithOverride for API familiarity. The
+// generated merge already overwrites on every non-nil diff value, so
+// this is currently a no-op.
+func WithOverride() MergeOption {
+	return func(mo *MergeOptions) { mo.override = true }
+}
+
+// WithoutOverwriteEmpty skips assigning a scalar field when the diff's
+// value is that field's zero value.
+func WithoutOverwriteEmpty() MergeOption {
+	return func(mo *MergeOptions) { mo.overwriteEmpty = false }
+}
+
+// WithAppendSlice makes every slice-diff Insert op append to the result
+// regardless of its recorded index, instead of reconstructing the slice
+// positionally.
+func WithAppendSlice() MergeOption {
+	return func(mo *MergeOptions) { mo.appendSlice = true }
+}
+
+// WithoutMapDelete makes a nil map-entry diff value a no-op instead of
+// deleting the entry from the destination map.
+func WithoutMapDelete() MergeOption {
+	return func(mo *MergeOptions) { mo.mapDelete = false }
+}
+
+// WithTypeTransformer registers fn to merge values of type t, consulted
+// before the generated field-merge logic runs for any scalar field
+// whose type matches t. It is not consulted for struct, map, or slice
+// fields: their Diff only carries the delta (a nested *<Type>Diff, a
+// DiffMap, or a slice edit script), not the field's full new value, so
+// there's nothing of type t to hand a transformer as src.
+func WithTypeTransformer(t reflect.Type, fn func(dst, src interface{}) error) MergeOption {
+	return func(mo *MergeOptions) {
+		if mo.transformers == nil {
+			mo.transformers = map[reflect.Type]func(dst, src interface{}) error{}
+		}
+		mo.transformers[t] = fn
+	}
+}
+
+func (mo *MergeOptions) Override() bool       { return mo.override }
+func (mo *MergeOptions) OverwriteEmpty() bool { return mo.overwriteEmpty }
+func (mo *MergeOptions) AppendSlice() bool    { return mo.appendSlice }
+func (mo *MergeOptions) MapDelete() bool      { return mo.mapDelete }
+
+// Transformer returns the registered transformer for t, if any.
+func (mo *MergeOptions) Transformer(t reflect.Type) (func(dst, src interface{}) error, bool) {
+	fn, ok := mo.transformers[t]
+	return fn, ok
+}
@@ -0,0 +1,77 @@
+package cgen
+
+// Data describes a set of Go struct types to generate Diff/Merge/Copy
+// code for. It is usually built by cgen/loader rather than by hand.
+type Data struct {
+	// Package is the package name the generated code is placed in.
+	Package string
+	// Structs holds the root type plus every struct type it
+	// transitively references, in dependency order.
+	Structs []Struct
+}
+
+// Struct describes one Go struct type to generate <Type>Diff, merge,
+// diff and Copy code for.
+type Struct struct {
+	// Type is the struct's Go type name.
+	Type string
+	// IsRoot marks the type that gets the exported Diff/Merge entry
+	// points; every other Struct only gets the unexported helpers.
+	IsRoot bool
+	// Fields are comparable, non-struct, non-map, non-slice fields.
+	Fields []Field
+	// StructFields are fields whose type is itself one of Structs.
+	StructFields []StructField
+	// Maps are fields keyed by a comparable type with a Struct value.
+	Maps []Map
+	// Slices are fields of slice type.
+	Slices []SliceField
+	// NilConditions are the "i.Field == nil" expressions used by the
+	// generated <Type>Diff.empty method.
+	NilConditions []string
+}
+
+// Field is a scalar (comparable) struct field.
+type Field struct {
+	Name string
+	Type string
+	Tag  string
+	// JSONName is the field's JSON Pointer token, used by the generated
+	// JSONPatch methods: the field's `json:"..."` name, or its Go name
+	// verbatim if no tag is set, matching what encoding/json emits.
+	JSONName string
+}
+
+// StructField is a struct field whose type is itself one of Data.Structs.
+type StructField struct {
+	Name     string
+	Type     string
+	Tag      string
+	JSONName string
+}
+
+// Map is a map field keyed by a comparable type with a struct value.
+type Map struct {
+	Field    string
+	Key      string
+	Value    string
+	Tag      string
+	JSONName string
+}
+
+// SliceField is a slice-typed struct field.
+type SliceField struct {
+	// Field is the struct field name.
+	Field string
+	// Elem is the element's Go type name.
+	Elem string
+	// IsStruct is true when Elem is itself one of Data.Structs, in
+	// which case elements diff recursively instead of by equality.
+	IsStruct bool
+	// KeyField, if set, names the Elem field used to match elements
+	// between the old and new slice (cgen:"key=<Field>"). If empty,
+	// IsStruct elements are matched positionally by index.
+	KeyField string
+	Tag      string
+	JSONName string
+}